@@ -0,0 +1,273 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Metrics counters exposed on /metrics.
+var (
+	dedupSuppressedTotal int64
+	groupsFlushedTotal   int64
+)
+
+// dedupCache is a bounded LRU of detection hashes with TTL expiry. It is
+// used to suppress identical detections (same host, query and columns)
+// seen again within the dedup window.
+type dedupCache struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	capacity int
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+type dedupEntry struct {
+	key     string
+	expires time.Time
+}
+
+func newDedupCache(ttl time.Duration, capacity int) *dedupCache {
+	return &dedupCache{
+		ttl:      ttl,
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// seen records key and reports whether it was already recorded and hasn't
+// yet expired. A miss (including an expired entry) inserts/refreshes key,
+// evicting the oldest entry once the cache is at capacity.
+func (c *dedupCache) seen(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if el, ok := c.entries[key]; ok {
+		entry := el.Value.(*dedupEntry)
+		if entry.expires.After(now) {
+			c.order.MoveToFront(el)
+			return true
+		}
+		c.order.Remove(el)
+		delete(c.entries, key)
+	}
+
+	el := c.order.PushFront(&dedupEntry{key: key, expires: now.Add(c.ttl)})
+	c.entries[key] = el
+
+	for c.capacity > 0 && c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		if oldest == nil {
+			break
+		}
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*dedupEntry).key)
+	}
+	return false
+}
+
+// detailHash hashes host + query name + sorted column key/values, so
+// identical detections are recognized regardless of column ordering.
+func detailHash(host string, d Detail) string {
+	keys := make([]string, 0, len(d.Columns))
+	for k := range d.Columns {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	sb.WriteString(host)
+	sb.WriteByte('|')
+	sb.WriteString(d.Name)
+	for _, k := range keys {
+		sb.WriteByte('|')
+		sb.WriteString(k)
+		sb.WriteByte('=')
+		sb.WriteString(d.Columns[k])
+	}
+
+	sum := sha256.Sum256([]byte(sb.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// pendingGroup accumulates notifications for one (host, category) pending
+// flush.
+type pendingGroup struct {
+	events []Notification
+	timer  *time.Timer
+}
+
+// GroupAggregator coalesces notifications arriving for the same
+// (host, category) within a time window into a single summary
+// notification, to avoid an alert storm from a noisy osquery diff.
+type GroupAggregator struct {
+	mu        sync.Mutex
+	window    time.Duration
+	maxEvents int
+	groups    map[string]*pendingGroup
+	flush     func(Notification)
+}
+
+// NewGroupAggregator builds a GroupAggregator that calls flush once per
+// group, either when window elapses since the group's first event or when
+// maxEvents is reached (0 disables the threshold).
+func NewGroupAggregator(window time.Duration, maxEvents int, flush func(Notification)) *GroupAggregator {
+	return &GroupAggregator{
+		window:    window,
+		maxEvents: maxEvents,
+		groups:    make(map[string]*pendingGroup),
+		flush:     flush,
+	}
+}
+
+// Add buffers n for coalescing. If window is zero or negative, grouping is
+// disabled and n is flushed immediately.
+func (g *GroupAggregator) Add(n Notification) {
+	if g.window <= 0 {
+		g.flush(n)
+		return
+	}
+
+	key := n.Host + "|" + n.Category
+
+	g.mu.Lock()
+	group, ok := g.groups[key]
+	if !ok {
+		group = &pendingGroup{}
+		g.groups[key] = group
+		group.timer = time.AfterFunc(g.window, func() { g.flushGroup(key) })
+	}
+	group.events = append(group.events, n)
+
+	var flushEvents []Notification
+	if g.maxEvents > 0 && len(group.events) >= g.maxEvents {
+		group.timer.Stop()
+		flushEvents = g.popLocked(key)
+	}
+	g.mu.Unlock()
+
+	// g.flush does synchronous outbound I/O (notifier/tracker requests), so
+	// it must never run with g.mu held or one slow destination would stall
+	// every other group's Add/flush.
+	if flushEvents != nil {
+		g.dispatch(flushEvents)
+	}
+}
+
+func (g *GroupAggregator) flushGroup(key string) {
+	g.mu.Lock()
+	events := g.popLocked(key)
+	g.mu.Unlock()
+
+	if events != nil {
+		g.dispatch(events)
+	}
+}
+
+// popLocked removes and returns key's buffered events, or nil if key has
+// already been flushed. Must be called with g.mu held.
+func (g *GroupAggregator) popLocked(key string) []Notification {
+	group, ok := g.groups[key]
+	if !ok {
+		return nil
+	}
+	delete(g.groups, key)
+	return group.events
+}
+
+// dispatch runs the flush callback for a popped group. Must be called
+// without g.mu held.
+func (g *GroupAggregator) dispatch(events []Notification) {
+	atomic.AddInt64(&groupsFlushedTotal, 1)
+	g.flush(summarize(events))
+}
+
+// FlushAll stops every pending group's timer and dispatches it immediately,
+// for use during graceful shutdown so a webhook already ACKed to FleetDM
+// isn't dropped just because it was still buffered in a GROUP_WINDOW.
+func (g *GroupAggregator) FlushAll() {
+	g.mu.Lock()
+	all := make([][]Notification, 0, len(g.groups))
+	for key, group := range g.groups {
+		group.timer.Stop()
+		all = append(all, g.popLocked(key))
+	}
+	g.mu.Unlock()
+
+	for _, events := range all {
+		g.dispatch(events)
+	}
+}
+
+// summarize combines multiple notifications for the same (host, category)
+// into a single notification listing every event.
+func summarize(events []Notification) Notification {
+	if len(events) == 1 {
+		return events[0]
+	}
+
+	first := events[0]
+	priority := first.Priority
+
+	var body strings.Builder
+	for i, e := range events {
+		if e.Priority > priority {
+			priority = e.Priority
+		}
+		body.WriteString(fmt.Sprintf("--- Event %d/%d ---\n", i+1, len(events)))
+		body.WriteString(e.Body)
+		body.WriteString("\n\n")
+	}
+
+	fingerprints := make([]string, len(events))
+	for i, e := range events {
+		fingerprints[i] = e.Fingerprint
+	}
+	sort.Strings(fingerprints)
+	groupSum := sha256.Sum256([]byte(strings.Join(fingerprints, "|")))
+
+	return Notification{
+		Title:       fmt.Sprintf("%s - %d events - %s", first.Category, len(events), first.Host),
+		Body:        strings.TrimSpace(body.String()),
+		Priority:    priority,
+		Tags:        first.Tags,
+		Category:    first.Category,
+		Host:        first.Host,
+		Topic:       first.Topic,
+		RouteTo:     first.RouteTo,
+		ForceTrack:  groupForceTrack(events),
+		Fingerprint: hex.EncodeToString(groupSum[:]),
+	}
+}
+
+// groupForceTrack reduces each event's rule-driven ForceTrack override
+// into one decision for the whole group: any event explicitly forcing a
+// ticket wins (an operator's "always track" shouldn't be silently dropped
+// because an earlier event in the same window didn't set it), otherwise
+// the first explicit override found, otherwise nil (fall back to the
+// priority threshold).
+func groupForceTrack(events []Notification) *bool {
+	var first *bool
+	for _, e := range events {
+		if e.ForceTrack == nil {
+			continue
+		}
+		if *e.ForceTrack {
+			forced := true
+			return &forced
+		}
+		if first == nil {
+			first = e.ForceTrack
+		}
+	}
+	return first
+}
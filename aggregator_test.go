@@ -0,0 +1,115 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDedupCacheSeen(t *testing.T) {
+	c := newDedupCache(50*time.Millisecond, 0)
+
+	if c.seen("a") {
+		t.Fatal("expected the first sighting of a key to report unseen")
+	}
+	if !c.seen("a") {
+		t.Fatal("expected a repeat sighting within the TTL to report seen")
+	}
+
+	time.Sleep(75 * time.Millisecond)
+	if c.seen("a") {
+		t.Fatal("expected a sighting after the TTL has elapsed to report unseen")
+	}
+}
+
+func TestDedupCacheCapacityEvictsOldest(t *testing.T) {
+	c := newDedupCache(time.Minute, 2)
+
+	c.seen("a")
+	c.seen("b")
+	c.seen("c") // capacity 2: should evict "a"
+
+	if !c.seen("b") {
+		t.Fatal("expected \"b\" to still be cached")
+	}
+	if c.seen("a") {
+		t.Fatal("expected the oldest key to have been evicted once capacity was exceeded")
+	}
+}
+
+func TestGroupAggregatorFlushesOnMaxEvents(t *testing.T) {
+	var flushed []Notification
+	ga := NewGroupAggregator(time.Hour, 2, func(n Notification) {
+		flushed = append(flushed, n)
+	})
+
+	ga.Add(Notification{Host: "h1", Category: "c1", Title: "first"})
+	if len(flushed) != 0 {
+		t.Fatalf("expected no flush before maxEvents is reached, got %d", len(flushed))
+	}
+
+	ga.Add(Notification{Host: "h1", Category: "c1", Title: "second"})
+	if len(flushed) != 1 {
+		t.Fatalf("expected exactly one flush once maxEvents is reached, got %d", len(flushed))
+	}
+	if flushed[0].Title == "" {
+		t.Fatal("expected the flushed notification to be a non-empty summary")
+	}
+}
+
+func TestGroupAggregatorFlushAll(t *testing.T) {
+	var mu sync.Mutex
+	flushedHosts := map[string]bool{}
+	ga := NewGroupAggregator(time.Hour, 0, func(n Notification) {
+		mu.Lock()
+		flushedHosts[n.Host] = true
+		mu.Unlock()
+	})
+
+	ga.Add(Notification{Host: "h1", Category: "c1"})
+	ga.Add(Notification{Host: "h2", Category: "c1"})
+
+	ga.FlushAll()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !flushedHosts["h1"] || !flushedHosts["h2"] {
+		t.Fatalf("expected FlushAll to dispatch every pending group, got %v", flushedHosts)
+	}
+	if len(ga.groups) != 0 {
+		t.Fatalf("expected FlushAll to clear all pending groups, %d remain", len(ga.groups))
+	}
+}
+
+// TestGroupAggregatorFlushDoesNotBlockOtherGroups guards against the flush
+// callback (synchronous outbound I/O in production) running while
+// GroupAggregator.mu is held: a slow flush for one group must not stall
+// Add() for an unrelated group.
+func TestGroupAggregatorFlushDoesNotBlockOtherGroups(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+
+	ga := NewGroupAggregator(time.Hour, 1, func(n Notification) {
+		if n.Host == "slow" {
+			started <- struct{}{}
+			<-release
+		}
+	})
+
+	go ga.Add(Notification{Host: "slow", Category: "x", Title: "slow-event"})
+	<-started
+
+	done := make(chan struct{})
+	go func() {
+		ga.Add(Notification{Host: "fast", Category: "y", Title: "fast-event"})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(200 * time.Millisecond):
+		t.Fatal("Add for an unrelated group blocked while another group's flush was in progress")
+	}
+
+	close(release)
+}
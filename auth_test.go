@@ -0,0 +1,161 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBearerTokenValid(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/webhook", nil)
+	req.Header.Set("Authorization", "Bearer correct-token")
+
+	if !bearerTokenValid(req, "correct-token") {
+		t.Fatal("expected the matching bearer token to be accepted")
+	}
+	if bearerTokenValid(req, "other-token") {
+		t.Fatal("expected a mismatched bearer token to be rejected")
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/webhook", nil)
+	if bearerTokenValid(req, "correct-token") {
+		t.Fatal("expected a missing Authorization header to be rejected")
+	}
+}
+
+func TestSignatureValid(t *testing.T) {
+	secret := "s3cret"
+	body := []byte(`{"timestamp":"now","details":[]}`)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	sig := hex.EncodeToString(mac.Sum(nil))
+
+	config := Config{SigningSecret: secret, SignatureHeader: "X-Signature"}
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", nil)
+	req.Header.Set("X-Signature", sig)
+	if !signatureValid(req, body, config) {
+		t.Fatal("expected a correctly signed body to be accepted")
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/webhook", nil)
+	req.Header.Set("X-Signature", sig)
+	if signatureValid(req, []byte("tampered"), config) {
+		t.Fatal("expected a tampered body to be rejected")
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/webhook", nil)
+	if signatureValid(req, body, config) {
+		t.Fatal("expected a missing signature header to be rejected")
+	}
+}
+
+func TestIPAllowed(t *testing.T) {
+	config := Config{}
+	config.AllowedCIDRs, _ = parseCIDRs("10.0.0.0/8")
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", nil)
+	req.RemoteAddr = "10.1.2.3:1234"
+	if !ipAllowed(req, config) {
+		t.Fatal("expected an address inside the allowlisted CIDR to be allowed")
+	}
+
+	req.RemoteAddr = "192.168.1.1:1234"
+	if ipAllowed(req, config) {
+		t.Fatal("expected an address outside the allowlisted CIDR to be rejected")
+	}
+}
+
+func TestIPAllowedTrustProxy(t *testing.T) {
+	config := Config{TrustProxy: true}
+	config.AllowedCIDRs, _ = parseCIDRs("10.0.0.0/8")
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", nil)
+	req.RemoteAddr = "203.0.113.9:1234" // untrusted proxy hop
+	req.Header.Set("X-Forwarded-For", "10.1.2.3, 203.0.113.9")
+
+	if !ipAllowed(req, config) {
+		t.Fatal("expected the left-most X-Forwarded-For address to be used when TrustProxy is set")
+	}
+
+	config.TrustProxy = false
+	if ipAllowed(req, config) {
+		t.Fatal("expected RemoteAddr (not X-Forwarded-For) to be used when TrustProxy is unset")
+	}
+}
+
+func TestAuthMiddlewareRejectsWithoutCredentials(t *testing.T) {
+	config := Config{AuthToken: "secret-token"}
+	called := false
+	handler := authMiddleware(config, func(w http.ResponseWriter, r *http.Request) { called = true })
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader("{}"))
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	if called {
+		t.Fatal("expected next handler not to run without valid credentials")
+	}
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rec.Code)
+	}
+}
+
+func TestAuthMiddlewareAcceptsValidBearerToken(t *testing.T) {
+	config := Config{AuthToken: "secret-token"}
+	called := false
+	handler := authMiddleware(config, func(w http.ResponseWriter, r *http.Request) { called = true })
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader("{}"))
+	req.Header.Set("Authorization", "Bearer secret-token")
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	if !called {
+		t.Fatal("expected next handler to run with a valid bearer token")
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+}
+
+func TestAuthMiddlewareRejectsDisallowedIP(t *testing.T) {
+	config := Config{}
+	config.AllowedCIDRs, _ = parseCIDRs("10.0.0.0/8")
+	called := false
+	handler := authMiddleware(config, func(w http.ResponseWriter, r *http.Request) { called = true })
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader("{}"))
+	req.RemoteAddr = "192.168.1.1:1234"
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	if called {
+		t.Fatal("expected next handler not to run for a disallowed IP")
+	}
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", rec.Code)
+	}
+}
+
+func TestAuthMiddlewareOpenWhenUnconfigured(t *testing.T) {
+	config := Config{}
+	called := false
+	handler := authMiddleware(config, func(w http.ResponseWriter, r *http.Request) { called = true })
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader("{}"))
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	if !called {
+		t.Fatal("expected the endpoint to stay open when no auth is configured")
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+}
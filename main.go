@@ -1,16 +1,25 @@
 package main
 
 import (
-	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 )
 
 // FleetDM webhook payload structures
@@ -38,28 +47,160 @@ type Decorations struct {
 	Hostname string `json:"hostname"`
 }
 
-// ntfy notification structure
-type NtfyNotification struct {
-	Topic    string   `json:"topic"`
-	Title    string   `json:"title"`
-	Message  string   `json:"message"`
-	Priority int      `json:"priority"`
-	Tags     []string `json:"tags"`
-}
-
 // Config holds application configuration
 type Config struct {
-	ListenAddr string
-	NtfyURL    string
-	NtfyTopic  string
+	ListenAddr     string
+	NtfyURL        string
+	NtfyTopic      string
+	NotifyURLs     []string
+	RulesFile      string
+	DedupWindow    time.Duration
+	DedupCacheSize int
+	GroupWindow    time.Duration
+	GroupMaxEvents int
+
+	SigningSecret   string
+	SignatureHeader string
+	AuthToken       string
+	TrustProxy      bool
+	AllowedCIDRs    []*net.IPNet
+
+	JiraURL            string
+	JiraUser           string
+	JiraToken          string
+	JiraProject        string
+	JiraIssueType      string
+	GithubToken        string
+	GithubRepo         string
+	TrackerMinPriority int
+
+	NotifyClientTimeout time.Duration
+	ReadHeaderTimeout   time.Duration
+	ReadTimeout         time.Duration
+	WriteTimeout        time.Duration
+	IdleTimeout         time.Duration
+	ShutdownTimeout     time.Duration
+	QueueSize           int
+	WorkerCount         int
 }
 
 func loadConfig() Config {
-	return Config{
+	config := Config{
 		ListenAddr: getEnv("LISTEN_ADDR", ":8080"),
 		NtfyURL:    getEnv("NTFY_URL", "https://ntfy.sh"),
 		NtfyTopic:  getEnv("NTFY_TOPIC", "fleet-alerts"),
 	}
+
+	if raw := getEnv("NOTIFY_URLS", ""); raw != "" {
+		config.NotifyURLs = strings.Split(raw, ",")
+	} else {
+		config.NotifyURLs = []string{legacyNtfyURL(config.NtfyURL, config.NtfyTopic)}
+	}
+
+	config.RulesFile = getEnv("RULES_FILE", "")
+
+	config.DedupWindow = getDurationEnv("DEDUP_WINDOW", 5*time.Minute)
+	config.DedupCacheSize = getIntEnv("DEDUP_CACHE_SIZE", 10000)
+	config.GroupWindow = getDurationEnv("GROUP_WINDOW", 30*time.Second)
+	config.GroupMaxEvents = getIntEnv("GROUP_MAX_EVENTS", 50)
+
+	config.SigningSecret = getEnv("FLEET_SIGNING_SECRET", "")
+	config.SignatureHeader = getEnv("SIGNATURE_HEADER", "X-Fleet-Signature")
+	config.AuthToken = getEnv("AUTH_TOKEN", "")
+	config.TrustProxy = getEnv("TRUST_PROXY", "false") == "true"
+
+	if raw := getEnv("ALLOWED_CIDRS", ""); raw != "" {
+		cidrs, err := parseCIDRs(raw)
+		if err != nil {
+			log.Fatalf("Invalid ALLOWED_CIDRS: %v", err)
+		}
+		config.AllowedCIDRs = cidrs
+	}
+
+	if config.SigningSecret == "" && config.AuthToken == "" {
+		log.Printf("WARNING: no AUTH_TOKEN or FLEET_SIGNING_SECRET configured; /webhook is unauthenticated")
+	}
+
+	config.JiraURL = getEnv("JIRA_URL", "")
+	config.JiraUser = getEnv("JIRA_USER", "")
+	config.JiraToken = getEnv("JIRA_TOKEN", "")
+	config.JiraProject = getEnv("JIRA_PROJECT", "")
+	config.JiraIssueType = getEnv("JIRA_ISSUE_TYPE", "")
+	config.GithubToken = getEnv("GITHUB_TOKEN", "")
+	config.GithubRepo = getEnv("GITHUB_REPO", "")
+	config.TrackerMinPriority = getIntEnv("TRACKER_MIN_PRIORITY", 4)
+
+	config.NotifyClientTimeout = getDurationEnv("NOTIFY_CLIENT_TIMEOUT", 10*time.Second)
+	config.ReadHeaderTimeout = getDurationEnv("READ_HEADER_TIMEOUT", 5*time.Second)
+	config.ReadTimeout = getDurationEnv("READ_TIMEOUT", 10*time.Second)
+	config.WriteTimeout = getDurationEnv("WRITE_TIMEOUT", 10*time.Second)
+	config.IdleTimeout = getDurationEnv("IDLE_TIMEOUT", 60*time.Second)
+	config.ShutdownTimeout = getDurationEnv("SHUTDOWN_TIMEOUT", 15*time.Second)
+	config.QueueSize = getIntEnv("QUEUE_SIZE", 256)
+	config.WorkerCount = getIntEnv("WORKER_COUNT", 8)
+
+	return config
+}
+
+// parseCIDRs parses a comma-separated list of CIDR blocks, e.g.
+// "10.0.0.0/8,192.168.1.0/24".
+func parseCIDRs(raw string) ([]*net.IPNet, error) {
+	parts := strings.Split(raw, ",")
+	cidrs := make([]*net.IPNet, 0, len(parts))
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", part, err)
+		}
+		cidrs = append(cidrs, ipNet)
+	}
+	return cidrs, nil
+}
+
+func getDurationEnv(key string, fallback time.Duration) time.Duration {
+	raw := getEnv(key, "")
+	if raw == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		log.Printf("Invalid duration for %s=%q, using default %s: %v", key, raw, fallback, err)
+		return fallback
+	}
+	return d
+}
+
+func getIntEnv(key string, fallback int) int {
+	raw := getEnv(key, "")
+	if raw == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		log.Printf("Invalid integer for %s=%q, using default %d: %v", key, raw, fallback, err)
+		return fallback
+	}
+	return n
+}
+
+// legacyNtfyURL turns the original NTFY_URL/NTFY_TOPIC pair into an
+// equivalent "ntfy://" destination URL, so existing deployments keep
+// working unchanged when NOTIFY_URLS is not set.
+func legacyNtfyURL(ntfyURL, topic string) string {
+	parsed, err := url.Parse(ntfyURL)
+	if err != nil || parsed.Host == "" {
+		return "ntfy://ntfy.sh/" + topic
+	}
+
+	dest := "ntfy://" + parsed.Host + "/" + topic
+	if parsed.Scheme == "http" {
+		dest += "?scheme=http"
+	}
+	return dest
 }
 
 func getEnv(key, fallback string) string {
@@ -200,8 +341,12 @@ func groupDetailsByAction(details []Detail) map[string][]Detail {
 	return grouped
 }
 
-// formatNotification creates an ntfy notification from FleetDM webhook payload
-func formatNotification(payload WebhookPayload, config Config) *NtfyNotification {
+// formatNotification creates a transport-agnostic Notification from a
+// FleetDM webhook payload. If rules is non-nil, the first matching rule's
+// action overrides the default category/priority/tags/topic/routing, or
+// suppresses the notification entirely; rules is nil-safe so callers
+// without a RULES_FILE configured keep the original hard-coded behavior.
+func formatNotification(payload WebhookPayload, config Config, rules *RuleSet) *Notification {
 	if len(payload.Details) == 0 {
 		return nil
 	}
@@ -215,6 +360,22 @@ func formatNotification(payload WebhookPayload, config Config) *NtfyNotification
 
 	category, queryName := parseQueryName(first.Name)
 
+	var action RuleAction
+	if rules != nil {
+		action, _ = rules.Evaluate(EvalInput{
+			QueryName: queryName,
+			Category:  category,
+			Host:      hostname,
+			Columns:   first.Columns,
+		})
+		if action.Suppress {
+			return nil
+		}
+		if action.Category != "" {
+			category = action.Category
+		}
+	}
+
 	// Build title
 	title := fmt.Sprintf("%s - %s", queryName, hostname)
 
@@ -253,13 +414,42 @@ func formatNotification(payload WebhookPayload, config Config) *NtfyNotification
 		}
 	}
 
-	return &NtfyNotification{
-		Topic:    config.NtfyTopic,
-		Title:    title,
-		Message:  strings.TrimSpace(msg.String()),
-		Priority: getPriority(category),
-		Tags:     getTags(category),
+	priority := getPriority(category)
+	if action.Priority != 0 {
+		priority = action.Priority
+	}
+	tags := getTags(category)
+	if len(action.Tags) > 0 {
+		tags = action.Tags
+	}
+
+	return &Notification{
+		Title:       title,
+		Body:        strings.TrimSpace(msg.String()),
+		Priority:    priority,
+		Tags:        tags,
+		Category:    category,
+		Host:        hostname,
+		Topic:       action.Topic,
+		RouteTo:     action.RouteTo,
+		ForceTrack:  action.Track,
+		Fingerprint: fingerprintDetails(hostname, queryName, payload.Details),
+	}
+}
+
+// fingerprintDetails builds a tracker dedup key from the host, query name
+// and every detail's columns, so two distinct detections under the same
+// query/category/host (e.g. a different cmdline/path) don't collapse onto
+// the same ticket.
+func fingerprintDetails(host, queryName string, details []Detail) string {
+	hashes := make([]string, len(details))
+	for i, d := range details {
+		hashes[i] = detailHash(host, d)
 	}
+	sort.Strings(hashes)
+
+	sum := sha256.Sum256([]byte(queryName + "|" + strings.Join(hashes, "|")))
+	return hex.EncodeToString(sum[:])
 }
 
 // getIdentifier tries to extract a meaningful identifier from columns
@@ -275,29 +465,8 @@ func getIdentifier(columns map[string]string) string {
 	return ""
 }
 
-// sendToNtfy sends a notification to the ntfy server
-func sendToNtfy(notification *NtfyNotification, config Config) error {
-	jsonData, err := json.Marshal(notification)
-	if err != nil {
-		return fmt.Errorf("failed to marshal notification: %w", err)
-	}
-
-	resp, err := http.Post(config.NtfyURL, "application/json", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return fmt.Errorf("failed to send to ntfy: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode >= 400 {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("ntfy returned error %d: %s", resp.StatusCode, string(body))
-	}
-
-	return nil
-}
-
 // webhookHandler handles incoming FleetDM webhook requests
-func webhookHandler(config Config) http.HandlerFunc {
+func webhookHandler(pool *WorkerPool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -319,22 +488,105 @@ func webhookHandler(config Config) http.HandlerFunc {
 			return
 		}
 
-		notification := formatNotification(payload, config)
-		if notification == nil {
-			log.Printf("No details in webhook payload")
+		if !pool.Enqueue(r.Context(), payload) {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Queue full, try again shortly", http.StatusServiceUnavailable)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
+	}
+}
+
+// healthzHandler is a liveness probe: if the process can answer HTTP at
+// all, it reports healthy.
+func healthzHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
+	}
+}
+
+// readyzHandler is a readiness probe: it fails once the worker queue is
+// full or a downstream notifier is unreachable, so a load balancer can
+// stop sending traffic before requests start getting 503s.
+func readyzHandler(pool *WorkerPool, dispatcher *Dispatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		depth, capacity := pool.QueueDepth(), pool.QueueCapacity()
+
+		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+		defer cancel()
+		pingErr := dispatcher.Ping(ctx)
+
+		ready := depth < capacity && pingErr == nil
+
+		status := http.StatusOK
+		if !ready {
+			status = http.StatusServiceUnavailable
+		}
+
+		resp := map[string]any{
+			"ready":         ready,
+			"queue_depth":   depth,
+			"queue_cap":     capacity,
+			"notify_status": "ok",
+		}
+		if pingErr != nil {
+			resp["notify_status"] = pingErr.Error()
+		}
+
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// dedupDetails drops details whose (host, query, columns) hash was seen
+// within the dedup window, incrementing dedup_suppressed_total for each.
+func dedupDetails(details []Detail, dedup *dedupCache) []Detail {
+	if dedup == nil {
+		return details
+	}
+
+	kept := make([]Detail, 0, len(details))
+	for _, d := range details {
+		host := d.Decorations.Hostname
+		if host == "" {
+			host = d.HostIdentifier
+		}
+
+		if dedup.seen(detailHash(host, d)) {
+			atomic.AddInt64(&dedupSuppressedTotal, 1)
+			continue
+		}
+		kept = append(kept, d)
+	}
+	return kept
+}
+
+// rulesValidateHandler parses and compiles the configured RULES_FILE
+// without installing it, reporting any errors as JSON. Useful for CI or an
+// operator checking a new rules file before a SIGHUP reload.
+func rulesValidateHandler(config Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if config.RulesFile == "" {
 			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(map[string]any{"valid": true, "error": nil})
 			return
 		}
 
-		if err := sendToNtfy(notification, config); err != nil {
-			log.Printf("Error sending to ntfy: %v", err)
-			http.Error(w, "Failed to send notification", http.StatusInternalServerError)
+		if err := ValidateRuleFile(config.RulesFile); err != nil {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			json.NewEncoder(w).Encode(map[string]any{"valid": false, "error": err.Error()})
 			return
 		}
 
-		log.Printf("Notification sent: %s", notification.Title)
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
+		json.NewEncoder(w).Encode(map[string]any{"valid": true, "error": nil})
 	}
 }
 
@@ -343,13 +595,111 @@ func main() {
 
 	log.Printf("Starting fleet-ntfy-webhook server")
 	log.Printf("  Listen address: %s", config.ListenAddr)
-	log.Printf("  ntfy URL: %s", config.NtfyURL)
-	log.Printf("  ntfy topic: %s", config.NtfyTopic)
+	log.Printf("  Notify URLs: %s", strings.Join(config.NotifyURLs, ", "))
+
+	ConfigureNotifyClientTimeout(config.NotifyClientTimeout)
+
+	dispatcher, err := NewDispatcher(config.NotifyURLs)
+	if err != nil {
+		log.Fatalf("Failed to configure notifiers: %v", err)
+	}
+
+	var rules *RuleSet
+	if config.RulesFile != "" {
+		log.Printf("  Rules file: %s", config.RulesFile)
+		rules, err = LoadRuleSet(config.RulesFile)
+		if err != nil {
+			log.Fatalf("Failed to load rules file: %v", err)
+		}
+		watchRulesReload(config.RulesFile, rules)
+	}
+
+	dedup := newDedupCache(config.DedupWindow, config.DedupCacheSize)
+	trackers := NewTrackerManager(config)
+
+	groups := NewGroupAggregator(config.GroupWindow, config.GroupMaxEvents, func(n Notification) {
+		if err := dispatcher.SendTo(context.Background(), n.RouteTo, n); err != nil {
+			log.Printf("Error dispatching notification: %v", err)
+		} else {
+			log.Printf("Notification sent: %s", n.Title)
+		}
+
+		if trackers.Enabled() && shouldTrack(n, config) {
+			if err := trackers.CreateOrUpdate(context.Background(), n); err != nil {
+				log.Printf("Error filing ticket: %v", err)
+			}
+		}
+	})
+
+	pool := NewWorkerPool(config.QueueSize, config.WorkerCount, config, rules, dedup, groups)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/webhook", authMiddleware(config, webhookHandler(pool)))
+	mux.HandleFunc("/rules/validate", rulesValidateHandler(config))
+	mux.HandleFunc("/metrics", metricsHandler())
+	mux.HandleFunc("/healthz", healthzHandler())
+	mux.HandleFunc("/readyz", readyzHandler(pool, dispatcher))
+
+	srv := &http.Server{
+		Addr:              config.ListenAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: config.ReadHeaderTimeout,
+		ReadTimeout:       config.ReadTimeout,
+		WriteTimeout:      config.WriteTimeout,
+		IdleTimeout:       config.IdleTimeout,
+	}
+
+	go func() {
+		log.Printf("Server listening on %s", config.ListenAddr)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Server failed: %v", err)
+		}
+	}()
 
-	http.HandleFunc("/webhook", webhookHandler(config))
+	waitForShutdown(srv, pool, groups, config.ShutdownTimeout)
+}
 
-	log.Printf("Server listening on %s", config.ListenAddr)
-	if err := http.ListenAndServe(config.ListenAddr, nil); err != nil {
-		log.Fatalf("Server failed: %v", err)
+// waitForShutdown blocks until SIGINT/SIGTERM, then drains in-flight HTTP
+// requests, already-enqueued worker jobs, and any notifications still
+// buffered in groups within timeout before returning. Shutting down the
+// server first stops new jobs from being enqueued; draining the pool
+// after ensures a job already ACKed to FleetDM still gets processed, and
+// flushing the groups after that ensures one still sitting in a
+// GROUP_WINDOW isn't dropped either.
+func waitForShutdown(srv *http.Server, pool *WorkerPool, groups *GroupAggregator, timeout time.Duration) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	<-sigCh
+
+	log.Printf("Shutting down...")
+	deadline := time.Now().Add(timeout)
+
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Graceful shutdown did not complete cleanly: %v", err)
 	}
+
+	if !pool.Stop(time.Until(deadline)) {
+		log.Printf("Worker pool did not drain before shutdown timeout; some queued jobs may not have been processed")
+	}
+
+	groups.FlushAll()
+}
+
+// watchRulesReload reloads rules from path whenever the process receives
+// SIGHUP, so operators can tune detection rules without a restart.
+func watchRulesReload(path string, rules *RuleSet) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP)
+
+	go func() {
+		for range sigCh {
+			if err := rules.Reload(path); err != nil {
+				log.Printf("Failed to reload rules file %s: %v", path, err)
+				continue
+			}
+			log.Printf("Reloaded rules file %s", path)
+		}
+	}()
 }
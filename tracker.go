@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Tracker opens or updates an issue-tracker ticket for a detection,
+// alongside (or instead of) a push notification.
+type Tracker interface {
+	CreateOrUpdate(ctx context.Context, n Notification) (id string, err error)
+}
+
+// TrackerManager fans a Notification out to every configured Tracker
+// concurrently, mirroring Dispatcher's behavior for push notifiers.
+type TrackerManager struct {
+	trackers []Tracker
+}
+
+// NewTrackerManager builds a TrackerManager from the Jira/GitHub clients
+// implied by the JIRA_*/GITHUB_* config. A Config that configures neither
+// yields an empty, no-op manager.
+func NewTrackerManager(config Config) *TrackerManager {
+	m := &TrackerManager{}
+
+	if config.JiraURL != "" && config.JiraProject != "" {
+		m.trackers = append(m.trackers, newJiraTracker(config))
+	}
+	if config.GithubRepo != "" && config.GithubToken != "" {
+		m.trackers = append(m.trackers, newGithubTracker(config))
+	}
+
+	return m
+}
+
+// Enabled reports whether any tracker is configured.
+func (m *TrackerManager) Enabled() bool {
+	return len(m.trackers) > 0
+}
+
+// CreateOrUpdate files or updates a ticket with every configured tracker,
+// aggregating failures so one tracker's outage doesn't block the others.
+func (m *TrackerManager) CreateOrUpdate(ctx context.Context, n Notification) error {
+	if len(m.trackers) == 0 {
+		return nil
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+
+	for _, t := range m.trackers {
+		wg.Add(1)
+		go func(tr Tracker) {
+			defer wg.Done()
+			if _, err := tr.CreateOrUpdate(ctx, n); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(t)
+	}
+	wg.Wait()
+
+	if len(errs) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.Error()
+	}
+	return fmt.Errorf("%d/%d tracker(s) failed: %s", len(errs), len(m.trackers), strings.Join(msgs, "; "))
+}
+
+// fingerprint identifies a detection for dedup against existing open
+// tickets, independent of wording changes in the notification body. It
+// uses n.Fingerprint (host + query + column hash, set by
+// formatNotification/summarize) when available, falling back to
+// host+category+title for a Notification built some other way.
+func fingerprint(n Notification) string {
+	if n.Fingerprint != "" {
+		return n.Fingerprint
+	}
+	sum := sha256.Sum256([]byte(n.Host + "|" + n.Category + "|" + n.Title))
+	return hex.EncodeToString(sum[:])
+}
+
+// shouldTrack reports whether n should be filed as a ticket: an explicit
+// per-rule override takes precedence, otherwise the priority threshold
+// configured via TRACKER_MIN_PRIORITY applies.
+func shouldTrack(n Notification, config Config) bool {
+	if n.ForceTrack != nil {
+		return *n.ForceTrack
+	}
+	return n.Priority >= config.TrackerMinPriority
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var trackerHTTPClient = &http.Client{Timeout: 15 * time.Second}
+
+// --- Jira ---------------------------------------------------------------
+
+// jiraTracker files/updates issues in a Jira project via the REST API,
+// authenticating with basic auth (email/PAT, per Jira Cloud API tokens).
+type jiraTracker struct {
+	baseURL   string
+	user      string
+	token     string
+	project   string
+	issueType string
+}
+
+func newJiraTracker(config Config) *jiraTracker {
+	issueType := config.JiraIssueType
+	if issueType == "" {
+		issueType = "Bug"
+	}
+	return &jiraTracker{
+		baseURL:   strings.TrimRight(config.JiraURL, "/"),
+		user:      config.JiraUser,
+		token:     config.JiraToken,
+		project:   config.JiraProject,
+		issueType: issueType,
+	}
+}
+
+type jiraSearchResponse struct {
+	Issues []struct {
+		Key string `json:"key"`
+	} `json:"issues"`
+}
+
+func (j *jiraTracker) do(ctx context.Context, method, path string, body any, out any) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal jira request: %w", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, j.baseURL+path, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to build jira request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(j.user, j.token)
+
+	resp, err := trackerHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("jira request to %s failed: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("jira %s %s returned %d: %s", method, path, resp.StatusCode, string(respBody))
+	}
+	if out != nil && len(respBody) > 0 {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("failed to parse jira response from %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// findExisting searches for an open issue carrying fp in its description,
+// returning its key or "" if none is found.
+func (j *jiraTracker) findExisting(ctx context.Context, fp string) (string, error) {
+	jql := fmt.Sprintf(`project = "%s" AND text ~ "%s" AND statusCategory != Done`, j.project, fp)
+	path := "/rest/api/2/search?jql=" + url.QueryEscape(jql)
+
+	var result jiraSearchResponse
+	if err := j.do(ctx, http.MethodGet, path, nil, &result); err != nil {
+		return "", err
+	}
+	if len(result.Issues) == 0 {
+		return "", nil
+	}
+	return result.Issues[0].Key, nil
+}
+
+func (j *jiraTracker) CreateOrUpdate(ctx context.Context, n Notification) (string, error) {
+	fp := fingerprint(n)
+
+	key, err := j.findExisting(ctx, fp)
+	if err != nil {
+		return "", err
+	}
+
+	if key != "" {
+		comment := map[string]any{"body": fmt.Sprintf("New occurrence at %s:\n%s", time.Now().UTC().Format(time.RFC3339), n.Body)}
+		if err := j.do(ctx, http.MethodPost, "/rest/api/2/issue/"+key+"/comment", comment, nil); err != nil {
+			return "", err
+		}
+		return key, nil
+	}
+
+	create := map[string]any{
+		"fields": map[string]any{
+			"project":     map[string]string{"key": j.project},
+			"issuetype":   map[string]string{"name": j.issueType},
+			"summary":     n.Title,
+			"description": fmt.Sprintf("%s\n\nFingerprint: %s", n.Body, fp),
+			"labels":      categoryLabels(n.Category, n.Tags),
+		},
+	}
+
+	var created struct {
+		Key string `json:"key"`
+	}
+	if err := j.do(ctx, http.MethodPost, "/rest/api/2/issue", create, &created); err != nil {
+		return "", err
+	}
+	return created.Key, nil
+}
+
+// --- GitHub ---------------------------------------------------------------
+
+// githubTracker files/updates issues in a GitHub repo via the REST API.
+type githubTracker struct {
+	repo  string // "owner/name"
+	token string
+}
+
+func newGithubTracker(config Config) *githubTracker {
+	return &githubTracker{repo: config.GithubRepo, token: config.GithubToken}
+}
+
+func (g *githubTracker) do(ctx context.Context, method, path string, body any, out any) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal github request: %w", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, "https://api.github.com"+path, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to build github request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+g.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := trackerHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("github request to %s failed: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("github %s %s returned %d: %s", method, path, resp.StatusCode, string(respBody))
+	}
+	if out != nil && len(respBody) > 0 {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("failed to parse github response from %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+type githubSearchResponse struct {
+	Items []struct {
+		Number int `json:"number"`
+	} `json:"items"`
+}
+
+func (g *githubTracker) findExisting(ctx context.Context, fp string) (int, error) {
+	query := fmt.Sprintf("repo:%s is:issue in:body %s", g.repo, fp)
+	path := "/search/issues?q=" + url.QueryEscape(query)
+
+	var result githubSearchResponse
+	if err := g.do(ctx, http.MethodGet, path, nil, &result); err != nil {
+		return 0, err
+	}
+	if len(result.Items) == 0 {
+		return 0, nil
+	}
+	return result.Items[0].Number, nil
+}
+
+func (g *githubTracker) CreateOrUpdate(ctx context.Context, n Notification) (string, error) {
+	fp := fingerprint(n)
+
+	number, err := g.findExisting(ctx, fp)
+	if err != nil {
+		return "", err
+	}
+
+	if number != 0 {
+		comment := map[string]string{"body": fmt.Sprintf("New occurrence at %s:\n%s", time.Now().UTC().Format(time.RFC3339), n.Body)}
+		path := fmt.Sprintf("/repos/%s/issues/%d/comments", g.repo, number)
+		if err := g.do(ctx, http.MethodPost, path, comment, nil); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d", number), nil
+	}
+
+	create := map[string]any{
+		"title":  n.Title,
+		"body":   fmt.Sprintf("%s\n\nFingerprint: %s", n.Body, fp),
+		"labels": categoryLabels(n.Category, n.Tags),
+	}
+
+	var created struct {
+		Number int `json:"number"`
+	}
+	if err := g.do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/issues", g.repo), create, &created); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d", created.Number), nil
+}
+
+// categoryLabels derives issue labels from a detection's category and
+// ntfy tags, e.g. "detection/persistence".
+func categoryLabels(category string, tags []string) []string {
+	labels := make([]string, 0, 1+len(tags))
+	if category != "" {
+		labels = append(labels, "detection/"+strings.ToLower(category))
+	}
+	for _, t := range tags {
+		if t != "warning" && t != "computer" {
+			labels = append(labels, t)
+		}
+	}
+	return labels
+}
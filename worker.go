@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// job is one webhook payload queued for processing.
+type job struct {
+	payload WebhookPayload
+}
+
+// WorkerPool bounds how many webhook payloads are processed concurrently,
+// so a burst of FleetDM webhooks can't spawn unbounded goroutines toward
+// the configured notifiers. Payloads that don't fit in the queue are
+// rejected so the caller can retry (see Enqueue).
+type WorkerPool struct {
+	jobs   chan job
+	config Config
+	rules  *RuleSet
+	dedup  *dedupCache
+	groups *GroupAggregator
+	wg     sync.WaitGroup
+}
+
+// NewWorkerPool creates a WorkerPool with the given queue capacity and
+// starts workerCount goroutines draining it.
+func NewWorkerPool(queueSize, workerCount int, config Config, rules *RuleSet, dedup *dedupCache, groups *GroupAggregator) *WorkerPool {
+	p := &WorkerPool{
+		jobs:   make(chan job, queueSize),
+		config: config,
+		rules:  rules,
+		dedup:  dedup,
+		groups: groups,
+	}
+	p.wg.Add(workerCount)
+	for i := 0; i < workerCount; i++ {
+		go p.run()
+	}
+	return p
+}
+
+func (p *WorkerPool) run() {
+	defer p.wg.Done()
+	for j := range p.jobs {
+		p.process(j)
+	}
+}
+
+// Stop closes the job queue and waits for every worker to finish its
+// current and already-queued jobs, up to timeout. It returns false if the
+// timeout elapsed first, meaning some already-ACKed jobs may not have been
+// processed. Enqueue must not be called after Stop.
+func (p *WorkerPool) Stop(timeout time.Duration) bool {
+	close(p.jobs)
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func (p *WorkerPool) process(j job) {
+	j.payload.Details = dedupDetails(j.payload.Details, p.dedup)
+	if len(j.payload.Details) == 0 {
+		return
+	}
+
+	notification := formatNotification(j.payload, p.config, p.rules)
+	if notification == nil {
+		return
+	}
+
+	p.groups.Add(*notification)
+}
+
+// Enqueue queues payload for processing and reports whether it fit in the
+// queue. A false result means either the queue was full or ctx was
+// already done (e.g. the client disconnected); either way the caller
+// should respond 503 with Retry-After.
+func (p *WorkerPool) Enqueue(ctx context.Context, payload WebhookPayload) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	default:
+	}
+
+	select {
+	case p.jobs <- job{payload: payload}:
+		return true
+	default:
+		return false
+	}
+}
+
+// QueueDepth returns the number of payloads currently buffered.
+func (p *WorkerPool) QueueDepth() int {
+	return len(p.jobs)
+}
+
+// QueueCapacity returns the queue's buffer size.
+func (p *WorkerPool) QueueCapacity() int {
+	return cap(p.jobs)
+}